@@ -0,0 +1,218 @@
+package kadm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/YenchangChan/franz-go/pkg/kerr"
+	"github.com/YenchangChan/franz-go/pkg/kmsg"
+)
+
+// AlterPartitionAssignmentsReq is the input to AlterPartitionAssignments: it
+// maps topics to partitions to the replicas the partition should be
+// reassigned to.
+//
+// Per KIP-455, a partition entry with a nil (not empty) replica list cancels
+// any reassignment currently in progress for that partition rather than
+// starting a new one. An empty, non-nil replica list is invalid and returns
+// an error before any request is issued.
+type AlterPartitionAssignmentsReq map[string]map[int32][]int32
+
+// AlterPartitionAssignmentsResponse contains the response for an individual
+// partition from an AlterPartitionAssignments request.
+type AlterPartitionAssignmentsResponse struct {
+	Topic     string // Topic is the topic that was reassigned.
+	Partition int32  // Partition is the partition that was reassigned.
+	Err       error  // Err is any error preventing the reassignment from being accepted.
+}
+
+// AlterPartitionAssignmentsResponses contains per-partition responses to an
+// AlterPartitionAssignments request.
+type AlterPartitionAssignmentsResponses map[string]map[int32]AlterPartitionAssignmentsResponse
+
+// Error returns the first erroring partition response's error, or nil if
+// every partition in the response was accepted.
+func (rs AlterPartitionAssignmentsResponses) Error() error {
+	for _, partitions := range rs {
+		for _, r := range partitions {
+			if r.Err != nil {
+				return r.Err
+			}
+		}
+	}
+	return nil
+}
+
+// AlterPartitionAssignments reassigns the replicas for the given topic
+// partitions, per KIP-455. To cancel an in-progress reassignment for a
+// partition, pass a nil replica list for that partition (see
+// CancelPartitionReassignments for a convenience wrapper).
+//
+// The broker this request is issued to negotiates the request version using
+// the connection's advertised ApiVersions, the same as every other request
+// this client issues; callers do not need to check kversion themselves.
+func (cl *Client) AlterPartitionAssignments(ctx context.Context, reassignments AlterPartitionAssignmentsReq) (AlterPartitionAssignmentsResponses, error) {
+	req := kmsg.NewPtrAlterPartitionAssignmentsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	for topic, partitions := range reassignments {
+		reqTopic := kmsg.NewAlterPartitionAssignmentsRequestTopic()
+		reqTopic.Topic = topic
+		for partition, replicas := range partitions {
+			if replicas != nil && len(replicas) == 0 {
+				return nil, fmt.Errorf("invalid empty (non-nil) replica list for topic %q partition %d: pass a nil list to cancel a reassignment", topic, partition)
+			}
+			reqPartition := kmsg.NewAlterPartitionAssignmentsRequestTopicPartition()
+			reqPartition.Partition = partition
+			reqPartition.Replicas = replicas
+			reqTopic.Partitions = append(reqTopic.Partitions, reqPartition)
+		}
+		req.Topics = append(req.Topics, reqTopic)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return nil, maybeErrMessage(err, resp.ErrorMessage)
+	}
+
+	rs := make(AlterPartitionAssignmentsResponses, len(resp.Topics))
+	for _, topic := range resp.Topics {
+		tResps := make(map[int32]AlterPartitionAssignmentsResponse, len(topic.Partitions))
+		for _, partition := range topic.Partitions {
+			tResps[partition.Partition] = AlterPartitionAssignmentsResponse{
+				Topic:     topic.Topic,
+				Partition: partition.Partition,
+				Err:       maybeErrMessage(kerr.ErrorForCode(partition.ErrorCode), partition.ErrorMessage),
+			}
+		}
+		rs[topic.Topic] = tResps
+	}
+	return rs, nil
+}
+
+// CancelPartitionReassignments cancels any in-progress reassignment for the
+// given topic partitions. Partitions that are not currently being
+// reassigned come back with a NoReassignmentInProgress error.
+func (cl *Client) CancelPartitionReassignments(ctx context.Context, topics map[string][]int32) (AlterPartitionAssignmentsResponses, error) {
+	req := make(AlterPartitionAssignmentsReq, len(topics))
+	for topic, partitions := range topics {
+		cancels := make(map[int32][]int32, len(partitions))
+		for _, partition := range partitions {
+			cancels[partition] = nil
+		}
+		req[topic] = cancels
+	}
+	return cl.AlterPartitionAssignments(ctx, req)
+}
+
+// PartitionReassignment describes the state of an in-progress (or just
+// completed) partition reassignment, as returned by
+// ListPartitionReassignments.
+type PartitionReassignment struct {
+	Topic            string  // Topic is the topic being reassigned.
+	Partition        int32   // Partition is the partition being reassigned.
+	Replicas         []int32 // Replicas is the partition's current full replica set.
+	AddingReplicas   []int32 // AddingReplicas is the subset of Replicas being added by this reassignment.
+	RemovingReplicas []int32 // RemovingReplicas is the subset of Replicas being removed by this reassignment.
+}
+
+// Done returns whether this reassignment has no more replicas left to add
+// or remove, meaning the partition has finished (or was never) reassigning.
+func (p PartitionReassignment) Done() bool {
+	return len(p.AddingReplicas) == 0 && len(p.RemovingReplicas) == 0
+}
+
+// ListPartitionReassignmentsResponses contains the current reassignment
+// state of every partition returned by ListPartitionReassignments, keyed
+// first by topic and then by partition.
+type ListPartitionReassignmentsResponses map[string]map[int32]PartitionReassignment
+
+// ListPartitionReassignments returns the current reassignment state of the
+// given topic partitions. A nil or empty topics map asks the broker for
+// every ongoing reassignment cluster-wide; ListAllPartitionReassignments is
+// a convenience wrapper for this.
+func (cl *Client) ListPartitionReassignments(ctx context.Context, topics map[string][]int32) (ListPartitionReassignmentsResponses, error) {
+	req := kmsg.NewPtrListPartitionReassignmentsRequest()
+	req.TimeoutMillis = cl.timeoutMillis
+	for topic, partitions := range topics {
+		reqTopic := kmsg.NewListPartitionReassignmentsRequestTopic()
+		reqTopic.Topic = topic
+		reqTopic.Partitions = partitions
+		req.Topics = append(req.Topics, reqTopic)
+	}
+
+	resp, err := req.RequestWith(ctx, cl.cl)
+	if err != nil {
+		return nil, err
+	}
+	if err := kerr.ErrorForCode(resp.ErrorCode); err != nil {
+		return nil, maybeErrMessage(err, resp.ErrorMessage)
+	}
+
+	rs := make(ListPartitionReassignmentsResponses, len(resp.Topics))
+	for _, topic := range resp.Topics {
+		tResps := make(map[int32]PartitionReassignment, len(topic.Partitions))
+		for _, partition := range topic.Partitions {
+			tResps[partition.Partition] = PartitionReassignment{
+				Topic:            topic.Topic,
+				Partition:        partition.Partition,
+				Replicas:         partition.Replicas,
+				AddingReplicas:   partition.AddingReplicas,
+				RemovingReplicas: partition.RemovingReplicas,
+			}
+		}
+		rs[topic.Topic] = tResps
+	}
+	return rs, nil
+}
+
+// ListAllPartitionReassignments returns the current reassignment state of
+// every partition that is being reassigned cluster-wide.
+func (cl *Client) ListAllPartitionReassignments(ctx context.Context) (ListPartitionReassignmentsResponses, error) {
+	return cl.ListPartitionReassignments(ctx, nil)
+}
+
+// WaitPartitionReassignments polls ListPartitionReassignments for the given
+// topic partitions every pollInterval until none of them have any adding or
+// removing replicas left (i.e. every reassignment is Done), or until ctx is
+// canceled.
+func (cl *Client) WaitPartitionReassignments(ctx context.Context, topics map[string][]int32, pollInterval time.Duration) (ListPartitionReassignmentsResponses, error) {
+	for {
+		rs, err := cl.ListPartitionReassignments(ctx, topics)
+		if err != nil {
+			return nil, err
+		}
+		done := true
+		for _, partitions := range rs {
+			for _, r := range partitions {
+				if !r.Done() {
+					done = false
+				}
+			}
+		}
+		if done {
+			return rs, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// maybeErrMessage returns err, suffixed with msg's pretty-printed reason
+// when the broker provided one (e.g. "NO_REASSIGNMENT_IN_PROGRESS: No
+// reassignment is in progress for partition foo-0").
+func maybeErrMessage(err error, msg *string) error {
+	if err == nil {
+		return nil
+	}
+	if msg != nil && *msg != "" {
+		return fmt.Errorf("%w: %s", err, *msg)
+	}
+	return err
+}
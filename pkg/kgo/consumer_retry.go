@@ -0,0 +1,464 @@
+package kgo
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Retry topic header keys used by the RetryPipeline subsystem. These are
+// attached to the record republished to RetryTopic (or DLQTopic) so that the
+// redelivery goroutine, and any downstream tooling inspecting the DLQ, can
+// recover the original delivery coordinates without a side channel.
+const (
+	retryHeaderAttempt   = "x-retry-attempt"
+	retryHeaderNotBefore = "x-retry-not-before"
+	retryHeaderOrigTopic = "x-original-topic"
+	retryHeaderOrigPart  = "x-original-partition"
+	retryHeaderOrigOff   = "x-original-offset"
+	retryHeaderError     = "x-error"
+)
+
+// errRetryPipelineTransactional is returned by ConsumerRetry when produceCl
+// has a TransactionalID. Retry/DLQ produces are plain, non-transactional
+// produces (see produce); issuing one on a transactional client fails every
+// time with errNotInTransaction, so ConsumerRetry refuses to start rather
+// than silently never retrying or DLQing anything.
+var errRetryPipelineTransactional = errors.New("ConsumerRetry does not support a transactional produceCl; produce retry/DLQ records through your own transaction instead of using ConsumerRetry")
+
+// errRetryPipelineClosing is returned by Handle when p.Close is called
+// while a record is waiting out its redelivery delay.
+var errRetryPipelineClosing = errors.New("retry pipeline is closing")
+
+// RetryTopicDecision is returned by a RetryConfig's Classify function to
+// tell the retry subsystem what to do with a record that failed Consume.
+type RetryTopicDecision struct {
+	// Retry, if true, republishes the record to RetryTopic for later
+	// redelivery (subject to Attempts). Ignored if DLQ is also true.
+	Retry bool
+	// DLQ, if true, produces the record directly to DLQTopic without
+	// spending a retry attempt. Takes priority over Retry.
+	DLQ bool
+}
+
+// RetryConfig configures the opt-in consumer-side retry-topic and
+// dead-letter-queue subsystem started with ConsumerRetry. This mirrors the
+// cron-driven retry pattern used by community "konsumer"-style libraries:
+// rather than blocking the partition on a failing record, the record is
+// republished to RetryTopic with a delay header and redelivered later, and
+// moved to DLQTopic once Attempts redeliveries are exhausted.
+//
+// kgo has no built-in notion of a per-record consume callback -- PollFetches
+// just returns the records it fetched -- so this subsystem cannot intercept
+// failures on its own. Callers must route every record returned by
+// PollFetches through the RetryPipeline's Handle (or HandleBatch) method
+// instead of calling Consume directly, and must only mark/commit a record
+// once Handle/HandleBatch reports it durable; see ConsumerRetry.
+type RetryConfig struct {
+	// RetryTopic is the topic failing records are republished to. It
+	// must be pre-created with at least as many partitions as the
+	// subsystem should have redelivery parallelism.
+	RetryTopic string
+
+	// DLQTopic is the topic records are produced to once Attempts
+	// redeliveries have been exhausted, or Classify returns DLQ.
+	DLQTopic string
+
+	// Attempts is the maximum number of redeliveries attempted through
+	// RetryTopic before a record is given up on and sent to DLQTopic.
+	// The original delivery (before any retry) does not count against
+	// this.
+	Attempts int
+
+	// Backoff returns how long to wait before redelivering a record that
+	// has failed `attempt` times (1-indexed: 1 is the first retry). If
+	// nil, DefaultRetryBackoff is used.
+	Backoff func(attempt int) time.Duration
+
+	// PerBatch, if true, retries and DLQs an entire HandleBatch call as a
+	// unit whenever Classify decides to retry or DLQ any record within
+	// it ("transactional retry"): every record in the batch, not just
+	// the ones that individually failed, is produced to the same
+	// destination topic with the same decision. If false (the default),
+	// each record in a batch is classified and routed independently.
+	// PerBatch only applies to HandleBatch; it has no effect on
+	// redeliveries from RetryTopic, which are always handled one at a
+	// time.
+	PerBatch bool
+
+	// Classify decides what to do with a record that Consume returned an
+	// error for. If nil, the default classifier retries anything
+	// isRetriableBrokerErr considers retriable and DLQs everything else.
+	Classify func(err error, attempt int) RetryTopicDecision
+
+	// Consume processes a single record, including those redelivered
+	// from RetryTopic. A non-nil error routes the record through
+	// Classify.
+	Consume func(context.Context, *Record) error
+
+	// OnRetried, if non-nil, is called every time a record is
+	// republished to RetryTopic.
+	OnRetried func(r *Record, attempt int)
+
+	// OnDLQed, if non-nil, is called every time a record is produced to
+	// DLQTopic.
+	OnDLQed func(r *Record, err error)
+
+	// OnDropped, if non-nil, is called every time a record is dropped
+	// without being retried or DLQed because Classify returned neither.
+	OnDropped func(r *Record, err error)
+}
+
+// DefaultRetryBackoff is the default RetryConfig.Backoff: exponential
+// backoff starting at one second and capping at one minute, with up to 20%
+// jitter to avoid every delayed record in a batch waking up at once.
+func DefaultRetryBackoff(attempt int) time.Duration {
+	base := time.Second << uint(attempt-1) // attempt is 1-indexed
+	if base > time.Minute || base <= 0 {
+		base = time.Minute
+	}
+	jitter := time.Duration(rand.Int63n(int64(base) / 5))
+	return base + jitter
+}
+
+// defaultRetryClassify retries anything the client's own broker-error
+// classification considers retriable, and sends everything else straight to
+// the DLQ -- the same split the client already uses to decide whether to
+// retry a request at the connection level.
+func defaultRetryClassify(err error, _ int) RetryTopicDecision {
+	if isRetriableBrokerErr(err) {
+		return RetryTopicDecision{Retry: true}
+	}
+	return RetryTopicDecision{DLQ: true}
+}
+
+// RetryPipeline is the runtime handle returned by ConsumerRetry. It consumes
+// cfg.RetryTopic in the background on its own Client and, via Handle /
+// HandleBatch, classifies and republishes records the caller's own Consume
+// function failed on.
+type RetryPipeline struct {
+	cl      *Client // used to produce retried/DLQed records
+	retryCl *Client // used only to consume cfg.RetryTopic
+	cfg     RetryConfig
+
+	inFlight int64 // atomic: records produced to retry/DLQ topics but not yet durable
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// ConsumerRetry starts the retry-topic and dead-letter-queue subsystem
+// described by cfg. produceCl produces the retried/DLQed records, and is
+// typically the same Client the caller uses to produce its own results;
+// produceCl must not have a TransactionalID, since retry/DLQ produces are
+// plain produces (see RetryConfig), not transactional ones.
+//
+// retryConsumeCl must be a Client dedicated to consuming cfg.RetryTopic and
+// nothing else: ConsumerRetry adds cfg.RetryTopic to its consumed topics and
+// polls it in the background for redeliveries. retryConsumeCl must not be
+// shared with the caller's own consuming -- PollFetches results are not
+// partitioned by topic across concurrent callers on the same Client, so a
+// shared Client would have this pipeline's background poll randomly steal
+// records that belong to the caller's own topics.
+//
+// Every record the caller's own PollFetches returns must be routed through
+// the returned RetryPipeline's Handle or HandleBatch method in place of
+// calling cfg.Consume directly -- kgo itself has no concept of a per-record
+// consume callback to hook automatically.
+//
+// The returned RetryPipeline's Close must be called before either Client is
+// closed, since the pipeline keeps consuming and producing through them
+// until then.
+func ConsumerRetry(produceCl, retryConsumeCl *Client, cfg RetryConfig) (*RetryPipeline, error) {
+	if produceCl.cfg.txnID != nil {
+		return nil, errRetryPipelineTransactional
+	}
+	if cfg.Backoff == nil {
+		cfg.Backoff = DefaultRetryBackoff
+	}
+	if cfg.Classify == nil {
+		cfg.Classify = defaultRetryClassify
+	}
+	p := &RetryPipeline{
+		cl:      produceCl,
+		retryCl: retryConsumeCl,
+		cfg:     cfg,
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	retryConsumeCl.AddConsumeTopics(cfg.RetryTopic)
+	go p.run()
+	return p, nil
+}
+
+// InFlightDelayed reports the number of records this pipeline has produced
+// to the retry or DLQ topic but has not yet confirmed as durable. Intended
+// for metrics hooks (kgmetrics, kzap, klogrus) to expose alongside their
+// other client gauges.
+func (p *RetryPipeline) InFlightDelayed() int64 {
+	return atomic.LoadInt64(&p.inFlight)
+}
+
+// Handle routes r through cfg.Consume and, on error, through the retry/DLQ
+// classification. It is the single entry point callers must use for every
+// record PollFetches returns, whether r is a first delivery from one of the
+// caller's own topics or a redelivery from cfg.RetryTopic.
+//
+// Handle returns nil only once r's fate is durable: cfg.Consume succeeded,
+// or the retry/DLQ decision was confirmed produced (or, for a decision that
+// neither retries nor DLQs, intentionally dropped). The caller must not
+// mark or commit r's offset unless Handle returns nil; on a non-nil error,
+// leave r uncommitted so it is refetched and retried.
+func (p *RetryPipeline) Handle(ctx context.Context, r *Record) error {
+	orig := r
+	attempt := 0
+	if r.Topic == p.cfg.RetryTopic {
+		attempt = headerInt(r, retryHeaderAttempt)
+		if notBefore := headerInt64(r, retryHeaderNotBefore); notBefore > 0 {
+			if d := time.Until(time.UnixMilli(notBefore)); d > 0 {
+				t := time.NewTimer(d)
+				defer t.Stop()
+				select {
+				case <-t.C:
+				case <-p.quit:
+					return errRetryPipelineClosing
+				}
+			}
+		}
+		orig = originalRecord(r)
+	}
+
+	if err := p.cfg.Consume(ctx, orig); err != nil {
+		return p.handleFailure(orig, err, attempt+1)
+	}
+	return nil
+}
+
+// HandleBatch calls Handle for every record in records, returning a
+// parallel slice of errors: errs[i] is nil once records[i]'s fate is
+// durable (see Handle) and safe to commit; a non-nil errs[i] means
+// records[i] must be left uncommitted for redelivery.
+//
+// If cfg.PerBatch is set, a retry or DLQ decision on any one record in
+// records applies to every record in records as a unit -- including, on a
+// produce failure, leaving every record in the batch uncommitted -- rather
+// than each being classified and committed independently; see
+// RetryConfig.PerBatch. PerBatch assumes records are all first deliveries
+// from the caller's own topics; pass redeliveries from cfg.RetryTopic
+// through Handle (as run already does) instead.
+func (p *RetryPipeline) HandleBatch(ctx context.Context, records []*Record) []error {
+	errs := make([]error, len(records))
+	if !p.cfg.PerBatch {
+		for i, r := range records {
+			errs[i] = p.Handle(ctx, r)
+		}
+		return errs
+	}
+
+	type failed struct {
+		i   int
+		r   *Record
+		err error
+	}
+	var failures []failed
+	for i, r := range records {
+		if err := p.cfg.Consume(ctx, r); err != nil {
+			failures = append(failures, failed{i, r, err})
+		}
+	}
+	if len(failures) == 0 {
+		return errs
+	}
+	// One record's error decides the fate of the whole batch, so that a
+	// single decision (and a single topic to produce every record in the
+	// batch to) applies uniformly; that error is also the cause recorded
+	// for every record the batch produces.
+	decision := p.cfg.Classify(failures[0].err, 1)
+	if decision.Retry || decision.DLQ {
+		for _, r := range records {
+			if err := p.route(r, failures[0].err, 1, decision); err != nil {
+				for i := range errs {
+					errs[i] = err
+				}
+				return errs
+			}
+		}
+		return errs
+	}
+	for _, f := range failures {
+		errs[f.i] = p.route(f.r, f.err, 1, decision)
+	}
+	return errs
+}
+
+// handleFailure classifies a failed record and produces it onward to
+// RetryTopic or DLQTopic as appropriate, returning the produce error, if
+// any.
+func (p *RetryPipeline) handleFailure(orig *Record, err error, attempt int) error {
+	decision := p.cfg.Classify(err, attempt)
+	return p.route(orig, err, attempt, decision)
+}
+
+// route produces orig onward per decision, incrementing the in-flight
+// delayed-record count for the duration of the produce, and returns the
+// produce error, if any, so that the caller does not mark/commit orig's
+// offset until the retry/DLQ record is confirmed durable.
+func (p *RetryPipeline) route(orig *Record, err error, attempt int, decision RetryTopicDecision) error {
+	switch {
+	case decision.DLQ || attempt > p.cfg.Attempts:
+		if perr := p.produce(p.cfg.DLQTopic, orig, err, attempt); perr != nil {
+			return perr
+		}
+		if p.cfg.OnDLQed != nil {
+			p.cfg.OnDLQed(orig, err)
+		}
+	case decision.Retry:
+		if perr := p.produce(p.cfg.RetryTopic, orig, err, attempt); perr != nil {
+			return perr
+		}
+		if p.cfg.OnRetried != nil {
+			p.cfg.OnRetried(orig, attempt)
+		}
+	default:
+		if p.cfg.OnDropped != nil {
+			p.cfg.OnDropped(orig, err)
+		}
+	}
+	return nil
+}
+
+// produce synchronously produces orig to topic via produceCl and returns
+// the produce's error, if any -- nil means the record is durable.
+func (p *RetryPipeline) produce(topic string, orig *Record, cause error, attempt int) error {
+	atomic.AddInt64(&p.inFlight, 1)
+	defer atomic.AddInt64(&p.inFlight, -1)
+
+	out := &Record{Topic: topic, Key: orig.Key, Value: orig.Value}
+	out.Headers = append(out.Headers, orig.Headers...)
+	out.Headers = append(out.Headers,
+		RecordHeader{Key: retryHeaderAttempt, Value: []byte(strconv.Itoa(attempt))},
+		RecordHeader{Key: retryHeaderNotBefore, Value: []byte(strconv.FormatInt(time.Now().Add(p.cfg.Backoff(attempt)).UnixMilli(), 10))},
+		RecordHeader{Key: retryHeaderOrigTopic, Value: []byte(orig.Topic)},
+		RecordHeader{Key: retryHeaderOrigPart, Value: []byte(strconv.Itoa(int(orig.Partition)))},
+		RecordHeader{Key: retryHeaderOrigOff, Value: []byte(strconv.FormatInt(orig.Offset, 10))},
+		RecordHeader{Key: retryHeaderError, Value: []byte(cause.Error())},
+	)
+
+	var produceErr error
+	wait := make(chan struct{})
+	promise := func(_ *Record, err error) {
+		produceErr = err
+		if err != nil {
+			p.cl.cfg.logger.Log(LogLevelError, "failed to produce retried/DLQ record", "topic", topic, "err", err)
+		}
+		close(wait)
+	}
+	p.cl.Produce(context.Background(), out, promise)
+	<-wait
+	return produceErr
+}
+
+// run polls retryCl -- which only ever consumes cfg.RetryTopic -- for
+// redeliveries and hands each to Handle, marking it committed only once
+// Handle confirms its fate is durable. If Handle returns an error, the
+// record is left uncommitted: it will be refetched and redelivery retried
+// on a later poll.
+func (p *RetryPipeline) run() {
+	defer close(p.done)
+
+	// Cancel the poll below on Close, so that an idle RetryTopic does not
+	// keep run (and therefore Close) blocked in PollFetches.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		select {
+		case <-p.quit:
+			return
+		default:
+		}
+
+		fetches := p.retryCl.PollFetches(ctx)
+		if fetches.IsClientClosed() {
+			return
+		}
+
+		var wg sync.WaitGroup
+		fetches.EachRecord(func(r *Record) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := p.Handle(context.Background(), r); err != nil {
+					p.cl.cfg.logger.Log(LogLevelError, "leaving retry-topic record uncommitted after a failed retry/DLQ produce; it will be redelivered", "topic", r.Topic, "partition", r.Partition, "offset", r.Offset, "err", err)
+					return
+				}
+				p.retryCl.MarkCommitRecords(r)
+			}()
+		})
+		wg.Wait()
+	}
+}
+
+// Close stops p, waiting for any in-flight redelivery and buffered produce
+// to finish. It must be called before either underlying Client is closed.
+func (p *RetryPipeline) Close() {
+	close(p.quit)
+	<-p.done
+}
+
+func headerInt(r *Record, key string) int {
+	for _, h := range r.Headers {
+		if h.Key == key {
+			n, _ := strconv.Atoi(string(h.Value))
+			return n
+		}
+	}
+	return 0
+}
+
+func headerInt64(r *Record, key string) int64 {
+	for _, h := range r.Headers {
+		if h.Key == key {
+			n, _ := strconv.ParseInt(string(h.Value), 10, 64)
+			return n
+		}
+	}
+	return 0
+}
+
+// originalRecord rebuilds the record as it looked before it was wrapped for
+// RetryTopic, so that Consume sees the same original topic, partition, and
+// offset on every redelivery attempt, and so that a record retried more
+// than once is re-wrapped from its true original coordinates rather than
+// from the retry topic's.
+func originalRecord(r *Record) *Record {
+	orig := &Record{Key: r.Key, Value: r.Value}
+	for _, h := range r.Headers {
+		switch h.Key {
+		case retryHeaderOrigTopic:
+			orig.Topic = string(h.Value)
+		case retryHeaderOrigPart:
+			n, _ := strconv.Atoi(string(h.Value))
+			orig.Partition = int32(n)
+		case retryHeaderOrigOff:
+			n, _ := strconv.ParseInt(string(h.Value), 10, 64)
+			orig.Offset = n
+		case retryHeaderAttempt, retryHeaderNotBefore, retryHeaderError:
+			// internal bookkeeping headers, not part of the original record
+		default:
+			orig.Headers = append(orig.Headers, h)
+		}
+	}
+	return orig
+}
@@ -0,0 +1,80 @@
+package kgo
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDefaultRetryBackoff(t *testing.T) {
+	for _, attempt := range []int{1, 2, 5, 10, 30} {
+		d := DefaultRetryBackoff(attempt)
+		if d <= 0 {
+			t.Errorf("attempt %d: got non-positive backoff %v", attempt, d)
+		}
+		if d > time.Minute+time.Minute/5 {
+			t.Errorf("attempt %d: got %v, expected capped around one minute plus jitter", attempt, d)
+		}
+	}
+}
+
+func TestDefaultRetryClassify(t *testing.T) {
+	if d := defaultRetryClassify(io.EOF, 1); !d.Retry {
+		t.Errorf("got Retry=false for a retriable broker error, expected true")
+	}
+	if d := defaultRetryClassify(errors.New("boom"), 1); !d.DLQ {
+		t.Errorf("got DLQ=false for a non-retriable error, expected true")
+	}
+}
+
+func TestOriginalRecord(t *testing.T) {
+	wrapped := &Record{
+		Topic: "retry-topic",
+		Headers: []RecordHeader{
+			{Key: retryHeaderOrigTopic, Value: []byte("orders")},
+			{Key: retryHeaderOrigPart, Value: []byte("3")},
+			{Key: retryHeaderOrigOff, Value: []byte("42")},
+			{Key: retryHeaderAttempt, Value: []byte("1")},
+			{Key: retryHeaderNotBefore, Value: []byte("1700000000000")},
+			{Key: retryHeaderError, Value: []byte("boom")},
+			{Key: "user-header", Value: []byte("kept")},
+		},
+	}
+	orig := originalRecord(wrapped)
+	if orig.Topic != "orders" {
+		t.Errorf("got topic %q, expected %q", orig.Topic, "orders")
+	}
+	if orig.Partition != 3 {
+		t.Errorf("got partition %d, expected 3", orig.Partition)
+	}
+	if orig.Offset != 42 {
+		t.Errorf("got offset %d, expected 42", orig.Offset)
+	}
+	// The internal bookkeeping headers must be stripped, not just the
+	// coordinate ones: otherwise a record retried more than once would
+	// accumulate duplicate x-retry-attempt/x-retry-not-before/x-error
+	// headers from every prior attempt.
+	if len(orig.Headers) != 1 || orig.Headers[0].Key != "user-header" {
+		t.Fatalf("got headers %v, expected only the user header to survive", orig.Headers)
+	}
+}
+
+func TestHeaderIntHelpers(t *testing.T) {
+	r := &Record{Headers: []RecordHeader{
+		{Key: retryHeaderAttempt, Value: []byte("4")},
+		{Key: retryHeaderNotBefore, Value: []byte("1700000000000")},
+	}}
+	if n := headerInt(r, retryHeaderAttempt); n != 4 {
+		t.Errorf("got %d, expected 4", n)
+	}
+	if n := headerInt(r, "missing"); n != 0 {
+		t.Errorf("got %d for a missing header, expected 0", n)
+	}
+	if n := headerInt64(r, retryHeaderNotBefore); n != 1700000000000 {
+		t.Errorf("got %d, expected 1700000000000", n)
+	}
+	if n := headerInt64(r, "missing"); n != 0 {
+		t.Errorf("got %d for a missing header, expected 0", n)
+	}
+}
@@ -235,3 +235,131 @@ func (e *errUnknownCoordinator) Error() string {
 			" but did not reply with that broker in the broker list", e.key.name, e.key.typ, e.coordinator)
 	}
 }
+
+// ErrorClass broadly categorizes an error returned by this package, so that
+// callers -- including downstream plugins such as kgmetrics, kzap, and
+// klogrus -- can label or branch on errors uniformly instead of
+// reimplementing isRetriableBrokerErr / isSkippableBrokerErr themselves.
+//
+// This is deliberately read-only: an earlier draft of this API also added a
+// RetryPolicy client option to let callers override classification for the
+// client's own internal retries, but that internal retry loop lives outside
+// this file (in the sink/broker request machinery) and was never changed to
+// consult it, so the option did nothing and was removed rather than shipped
+// as dead scaffolding. Overriding the client's actual retry behavior is not
+// implemented here; ClassifyError/IsRetriable/IsFatal/IsAuth only let
+// callers observe the classification the client already uses.
+type ErrorClass int8
+
+const (
+	// ErrClassNone is returned by ClassifyError for a nil error.
+	ErrClassNone ErrorClass = iota
+	// ErrClassRetriable is returned for errors the client itself retries
+	// against the same broker (see isRetriableBrokerErr).
+	ErrClassRetriable
+	// ErrClassSkippableBroker is returned for errors where the chosen
+	// broker cannot be used but a different broker may succeed (see
+	// isSkippableBrokerErr).
+	ErrClassSkippableBroker
+	// ErrClassDataLoss is returned for *ErrDataLoss: Kafka detected data
+	// loss and the client reset to the last valid offset.
+	ErrClassDataLoss
+	// ErrClassAuth is returned for errors that report themselves as SASL
+	// / authentication failures through isAuthErr. No error in this
+	// module does so, so ClassifyError does not currently return it.
+	ErrClassAuth
+	// ErrClassClientClosed is returned for ErrClientClosed and errors
+	// that wrap it.
+	ErrClassClientClosed
+	// ErrClassProduceTimeout is returned for ErrRecordTimeout and
+	// ErrRecordRetries: the record is done retrying, but the client
+	// itself is fine.
+	ErrClassProduceTimeout
+	// ErrClassFatal is returned for everything else: an error the client
+	// itself would not retry.
+	ErrClassFatal
+)
+
+// String returns the ErrorClass's name, suitable for a metric label.
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrClassNone:
+		return "none"
+	case ErrClassRetriable:
+		return "retriable"
+	case ErrClassSkippableBroker:
+		return "skippable_broker"
+	case ErrClassDataLoss:
+		return "data_loss"
+	case ErrClassAuth:
+		return "auth"
+	case ErrClassClientClosed:
+		return "client_closed"
+	case ErrClassProduceTimeout:
+		return "produce_timeout"
+	default:
+		return "fatal"
+	}
+}
+
+// ClassifyError categorizes err the same way the client's internal retry
+// logic evaluates it, so that callers do not need to reimplement
+// isRetriableBrokerErr / isSkippableBrokerErr in their own retry wrappers,
+// metrics plugins, or DLQ pipelines.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrClassNone
+	}
+	if errors.Is(err, ErrClientClosed) {
+		return ErrClassClientClosed
+	}
+	var dataLoss *ErrDataLoss
+	if errors.As(err, &dataLoss) {
+		return ErrClassDataLoss
+	}
+	if errors.Is(err, ErrRecordTimeout) || errors.Is(err, ErrRecordRetries) {
+		return ErrClassProduceTimeout
+	}
+	if isAuthErr(err) {
+		return ErrClassAuth
+	}
+	if isRetriableBrokerErr(err) {
+		return ErrClassRetriable
+	}
+	if isSkippableBrokerErr(err) {
+		return ErrClassSkippableBroker
+	}
+	return ErrClassFatal
+}
+
+// IsRetriable returns whether the client itself would attempt err's request
+// again, on the same broker or (per ErrClassSkippableBroker) a different
+// one.
+func IsRetriable(err error) bool {
+	switch ClassifyError(err) {
+	case ErrClassRetriable, ErrClassSkippableBroker:
+		return true
+	}
+	return false
+}
+
+// IsFatal returns whether ClassifyError(err) is ErrClassFatal.
+func IsFatal(err error) bool {
+	return ClassifyError(err) == ErrClassFatal
+}
+
+// IsAuth returns whether ClassifyError(err) is ErrClassAuth.
+func IsAuth(err error) bool {
+	return ClassifyError(err) == ErrClassAuth
+}
+
+// isAuthErr reports whether err identifies itself as a SASL/authentication
+// failure via an unexported SASLError() bool method, the same optional-
+// interface pattern isRetriableBrokerErr uses for Temporary() bool. No
+// sasl mechanism in this module implements SASLError yet, so this currently
+// always returns false; it is here so that a mechanism's error type can opt
+// in later without ClassifyError/IsAuth needing to change.
+func isAuthErr(err error) bool {
+	var saslErr interface{ SASLError() bool }
+	return errors.As(err, &saslErr) && saslErr.SASLError()
+}
@@ -0,0 +1,138 @@
+package kversion
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/YenchangChan/franz-go/pkg/kmsg"
+)
+
+// Feature is a broker capability expressed as the minimum request key
+// version(s) required for it, so that code can ask "does this broker
+// support KIP-X?" instead of hardcoding key/version checks against a
+// Versions value.
+type Feature uint8
+
+const (
+	// FeatureIdempotentProducer reports support for KIP-98 idempotent
+	// production (InitProducerID, plus the idempotent fields on
+	// Produce).
+	FeatureIdempotentProducer Feature = iota
+	// FeatureTransactions reports support for KIP-98 transactions (the
+	// AddPartitionsToTxn / AddOffsetsToTxn / EndTxn family).
+	FeatureTransactions
+	// FeatureKIP455Reassignments reports support for KIP-455's
+	// AlterPartitionAssignments and ListPartitionReassignments requests.
+	FeatureKIP455Reassignments
+	// FeatureKIP482TaggedFields reports support for KIP-482 flexible
+	// (tagged field) versions, introduced with ApiVersions v3.
+	FeatureKIP482TaggedFields
+	// FeatureKIP447ConsumerGroupTxn reports support for KIP-447's
+	// per-group-generation transactional offset commits.
+	FeatureKIP447ConsumerGroupTxn
+	// FeatureReadCommittedIsolation reports support for fetching with
+	// the read_committed isolation level.
+	FeatureReadCommittedIsolation
+	// FeatureKIP848ConsumerProtocol reports support for KIP-848's next
+	// generation consumer group protocol.
+	FeatureKIP848ConsumerProtocol
+)
+
+// String returns the Feature's KIP-oriented name, suitable for logging.
+func (f Feature) String() string {
+	switch f {
+	case FeatureIdempotentProducer:
+		return "idempotent_producer"
+	case FeatureTransactions:
+		return "transactions"
+	case FeatureKIP455Reassignments:
+		return "kip_455_reassignments"
+	case FeatureKIP482TaggedFields:
+		return "kip_482_tagged_fields"
+	case FeatureKIP447ConsumerGroupTxn:
+		return "kip_447_consumer_group_txn"
+	case FeatureReadCommittedIsolation:
+		return "read_committed_isolation"
+	case FeatureKIP848ConsumerProtocol:
+		return "kip_848_consumer_protocol"
+	default:
+		return "unknown_feature"
+	}
+}
+
+// featureReq is one (request key, minimum version) requirement. A Feature
+// can require more than one of these to all be met at once.
+type featureReq struct {
+	key     int16
+	version int16
+}
+
+// featureTable maps every Feature to the request key/version requirements
+// that must all be satisfied for Versions.Supports to report it present.
+var featureTable = map[Feature][]featureReq{
+	FeatureIdempotentProducer:     {{22, 0}, {0, 3}},           // InitProducerID, Produce
+	FeatureTransactions:           {{24, 0}, {25, 0}, {26, 0}}, // AddPartitionsToTxn, AddOffsetsToTxn, EndTxn
+	FeatureKIP455Reassignments:    {{45, 0}, {46, 0}},          // AlterPartitionAssignments, ListPartitionReassignments
+	FeatureKIP482TaggedFields:     {{18, 3}},                   // ApiVersions
+	FeatureKIP447ConsumerGroupTxn: {{28, 3}},                   // TxnOffsetCommit
+	FeatureReadCommittedIsolation: {{1, 4}},                    // Fetch
+	FeatureKIP848ConsumerProtocol: {{68, 0}, {69, 0}},          // ConsumerGroupHeartbeat, ConsumerGroupDescribe
+}
+
+// Supports returns whether vs satisfies every request key/version
+// requirement behind feature. Unknown features (any Feature value outside
+// the table, such as one this version of kversion does not know about)
+// report false.
+func (vs Versions) Supports(feature Feature) bool {
+	reqs, ok := featureTable[feature]
+	if !ok {
+		return false
+	}
+	for _, req := range reqs {
+		if int(req.key) >= len(vs.k2v) || vs.k2v[req.key] < req.version {
+			return false
+		}
+	}
+	return true
+}
+
+// VersionGuessFeatures returns the same string VersionGuess would, with the
+// subset of fs that vs.Supports appended. This is useful for logging a
+// broker's guessed release alongside the handful of features the caller
+// actually cares about.
+func (vs Versions) VersionGuessFeatures(fs ...Feature) string {
+	guess := vs.VersionGuess()
+	var supported []string
+	for _, f := range fs {
+		if vs.Supports(f) {
+			supported = append(supported, f.String())
+		}
+	}
+	if len(supported) == 0 {
+		return guess
+	}
+	return guess + " (features: " + strings.Join(supported, ", ") + ")"
+}
+
+// FromApiVersionsResponse returns a Versions from a broker's ApiVersions
+// response, so that Supports and VersionGuess reflect what the broker
+// actually advertised rather than a guessed release.
+func FromApiVersionsResponse(r *kmsg.ApiVersionsResponse) Versions {
+	var vs Versions
+	for _, k := range r.ApiKeys {
+		vs.SetMaxKeyVersion(k.ApiKey, k.MaxVersion)
+	}
+	return vs
+}
+
+// MarshalJSON implements json.Marshaler, encoding vs as the max supported
+// version per request key (index == key, -1 meaning unsupported), so a
+// Versions can be cached or logged and later restored with UnmarshalJSON.
+func (vs Versions) MarshalJSON() ([]byte, error) {
+	return json.Marshal(vs.k2v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler; see MarshalJSON.
+func (vs *Versions) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &vs.k2v)
+}
@@ -1,7 +1,10 @@
 package kversion
 
 import (
+	"encoding/json"
 	"testing"
+
+	"github.com/YenchangChan/franz-go/pkg/kmsg"
 )
 
 func TestVersionGuess(t *testing.T) {
@@ -90,3 +93,59 @@ func TestEqual(t *testing.T) {
 		t.Errorf("unexpectedly not equal after backing v0.8.1 down to v0.8.0, opposite direction")
 	}
 }
+
+func TestSupports(t *testing.T) {
+	v := V0_8_0()
+	if v.Supports(FeatureKIP455Reassignments) {
+		t.Errorf("v0.8.0 unexpectedly supports KIP-455 reassignments")
+	}
+	if v.Supports(FeatureTransactions) {
+		t.Errorf("v0.8.0 unexpectedly supports transactions")
+	}
+
+	v = V2_7_0()
+	if !v.Supports(FeatureKIP455Reassignments) {
+		t.Errorf("v2.7.0 unexpectedly does not support KIP-455 reassignments")
+	}
+	if !v.Supports(FeatureTransactions) {
+		t.Errorf("v2.7.0 unexpectedly does not support transactions")
+	}
+
+	v.SetMaxKeyVersion(46, -1)
+	if v.Supports(FeatureKIP455Reassignments) {
+		t.Errorf("v2.7.0 unexpectedly supports KIP-455 reassignments after unsetting ListPartitionReassignments")
+	}
+}
+
+func TestFromApiVersionsResponse(t *testing.T) {
+	r := kmsg.ApiVersionsResponse{
+		ApiKeys: []kmsg.ApiVersionsResponseApiKey{
+			{ApiKey: 0, MaxVersion: 9},
+			{ApiKey: 45, MaxVersion: 0},
+			{ApiKey: 46, MaxVersion: 0},
+		},
+	}
+	v := FromApiVersionsResponse(&r)
+	if !v.Supports(FeatureKIP455Reassignments) {
+		t.Errorf("expected FromApiVersionsResponse result to support KIP-455 reassignments")
+	}
+	if v.Supports(FeatureTransactions) {
+		t.Errorf("expected FromApiVersionsResponse result to not support transactions")
+	}
+}
+
+func TestVersionsJSON(t *testing.T) {
+	v := V2_7_0()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+
+	var got Versions
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	if !got.Equal(v) {
+		t.Errorf("round-tripped Versions unexpectedly not equal to original")
+	}
+}